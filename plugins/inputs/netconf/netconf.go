@@ -19,160 +19,535 @@
 package netconf
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/Juniper/go-netconf/netconf"
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/plugins/inputs"
-	"golang.org/x/crypto/ssh"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/openconfig/goyang/pkg/yang"
 )
 
-// Device represents a single NETCONF device.
+// Device represents a single NETCONF device. Username, Password and the SSH
+// key fields are config.Secret so they can be written in TOML as plain
+// strings or as "@{secretstore:key}" references resolved lazily in
+// connect().
 type Device struct {
-	Address  string `toml:"address"`
-	Username string `toml:"username"`
-	Password string `toml:"password"`
+	Address string `toml:"address"`
+
+	Username config.Secret `toml:"username"`
+	Password config.Secret `toml:"password"`
+
+	// SSHPrivateKey, when set, is used instead of Password.
+	SSHPrivateKey           config.Secret `toml:"ssh_private_key"`
+	SSHPrivateKeyPassphrase config.Secret `toml:"ssh_private_key_passphrase"`
+
+	// KnownHostsFile, if set, is used to verify the device's host key. When
+	// left empty the connection falls back to ssh.InsecureIgnoreHostKey().
+	KnownHostsFile string `toml:"known_hosts"`
+
+	// LoggingAlias, if set, replaces Address in log messages and error tags
+	// for this device (handy when Address is an IP with no obvious meaning).
+	LoggingAlias string `toml:"logging_alias"`
+}
+
+// alias returns device's LoggingAlias if set, otherwise its Address.
+func (d Device) alias() string {
+	if d.LoggingAlias != "" {
+		return d.LoggingAlias
+	}
+	return d.Address
+}
+
+// Subscription describes one piece of NETCONF data to collect and how to
+// turn it into a metric. Origin selects how Path is interpreted: "subtree"
+// builds a classic NETCONF subtree filter out of the slash-separated
+// segments in Path, while "xpath" sends Path verbatim as an XPath select
+// expression (requires the device to advertise the :xpath capability).
+//
+// Path identifies the repeating element that becomes one metric per match
+// (e.g. "interfaces/interface"). TagPaths and FieldPaths are evaluated
+// relative to each match and become the tags/fields of the resulting
+// metric.
+//
+// For a "subtree" origin, Namespaces is keyed by path segment name: any
+// segment of Path found in Namespaces is given that entry's URI as its
+// default xmlns, so a filter spanning more than one YANG module can be
+// expressed (e.g. Namespaces = {"interfaces": "...ietf-interfaces"} when
+// Path's outermost segment is "interfaces"). For an "xpath" origin,
+// Namespaces is instead keyed by the prefixes used in Path itself and every
+// entry is declared as an xmlns:prefix on the filter.
+//
+// When DataFormat is set, the <rpc-reply> payload is handed to one of
+// Telegraf's registered parsers (e.g. DataFormat = "json" against a
+// RESTCONF-style device) instead; Path/TagPaths/FieldPaths are then ignored
+// in favor of whatever metrics that parser produces from the raw reply.
+type Subscription struct {
+	Name        string            `toml:"name"`
+	Origin      string            `toml:"origin"`
+	Path        string            `toml:"path"`
+	Namespaces  map[string]string `toml:"namespaces"`
+	Measurement string            `toml:"measurement"`
+	TagPaths    map[string]string `toml:"tag_paths"`
+	FieldPaths  map[string]string `toml:"field_paths"`
+	DataFormat  string            `toml:"data_format"`
+
+	parser telegraf.Parser
 }
 
 // Netconf is the main plugin struct.
 type Netconf struct {
-	Devices []Device `toml:"devices"`
+	Devices       []Device             `toml:"devices"`
+	Subscriptions []Subscription       `toml:"subscription"`
+	Streams       []StreamSubscription `toml:"stream"`
+
+	MaxReconnectInterval config.Duration `toml:"max_reconnect_interval"`
+
+	// HealthCheckInterval paces the keepalive sent on a cached connection
+	// before it is handed out again; zero disables keepalives entirely.
+	HealthCheckInterval config.Duration `toml:"health_check_interval"`
+
+	// DialTimeout bounds how long dialing a device's SSH/NETCONF session may
+	// take before giving up. Defaults to defaultDialTimeout when unset, so a
+	// single unreachable device can't block Gather (or a stream's reconnect
+	// loop) forever.
+	DialTimeout config.Duration `toml:"dial_timeout"`
 
-	// Map to store active connections (address -> session)
-	connections map[string]*netconf.Session
+	// Concurrency bounds how many devices are polled at once in Gather.
+	// Defaults to 1 (serial, the historical behavior) when unset.
+	Concurrency int `toml:"concurrency"`
+
+	// YangDir, if set, points at a directory of .yang modules used to type
+	// leaf values and derive tag/field names instead of requiring them in
+	// TagPaths/FieldPaths.
+	YangDir string `toml:"yang_dir"`
+	schema  *yangSchema
+
+	Log telegraf.Logger `toml:"-"`
+
+	// connections pools live sessions by device address.
+	connections map[string]*pooledSession
+	retries     map[string]*retryState
 	mu          sync.Mutex
+
+	// Streaming (RFC 5277) service-input state, populated by Start.
+	acc    telegraf.Accumulator
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
 // SampleConfig returns the default configuration for the plugin.
 func (n *Netconf) SampleConfig() string {
 	return `
-		## List of NETCONF devices to poll
+		## List of NETCONF devices to poll. username/password (and, for
+		## key-based auth, ssh_private_key/ssh_private_key_passphrase) accept
+		## either plain strings or "@{secretstore:key}" references.
 		[[inputs.netconf.devices]]
 		  address = "192.168.1.1:830"
 		  username = "admin"
 		  password = "password"
+		  # known_hosts = "/etc/telegraf/known_hosts"
 		# [[inputs.netconf.devices]]
 		#   address = "192.168.1.2:830"
 		#   username = "admin"
-		#   password = "password"
+		#   ssh_private_key = "@{secretstore:netconf_device2_key}"
+		#   ssh_private_key_passphrase = "@{secretstore:netconf_device2_key_passphrase}"
+		#   known_hosts = "/etc/telegraf/known_hosts"
+		#   logging_alias = "device2"  # used in log/error messages instead of the address
+
+		## One or more data collections to pull from every device above.
+		## "origin" is either "subtree" or "xpath".
+		[[inputs.netconf.subscription]]
+		  name        = "interface-stats"
+		  origin      = "subtree"
+		  path        = "interfaces/interface"
+		  measurement = "netconf_interface"
+		  namespaces  = { interfaces = "urn:ietf:params:xml:ns:yang:ietf-interfaces" }
+		  tag_paths   = { interface = "name" }
+		  field_paths = { input_bytes = "statistics/in-octets", output_bytes = "statistics/out-octets" }
+
+		## Alternative to path/tag_paths/field_paths above: hand the raw
+		## <rpc-reply> to one of Telegraf's registered parsers (e.g. a device
+		## that returns RESTCONF-style JSON in-band over NETCONF). The parser
+		## is built with its own defaults; data_format is the only supported
+		## option here, since the subscription table doesn't carry that
+		## parser's TOML config.
+		# [[inputs.netconf.subscription]]
+		#   name        = "interface-stats-json"
+		#   origin      = "subtree"
+		#   path        = "interfaces/interface"
+		#   measurement = "netconf_interface"
+		#   data_format = "json"
+
+		## Optional long-lived RFC 5277 notification streams, delivered to the
+		## accumulator asynchronously instead of on each Gather interval.
+		# [[inputs.netconf.stream]]
+		#   name        = "interface-events"
+		#   stream      = "NETCONF"
+		#   path        = "interface"
+		#   measurement = "netconf_interface_event"
+		#   tag_paths   = { interface = "if-name" }
+		#   field_paths = { admin_status = "admin-status" }
+
+		## Cap on the backoff between reconnection attempts for streams above.
+		# max_reconnect_interval = "5m"
+
+		## Optional directory of .yang modules. When set, tag_paths/field_paths
+		## become optional: list keys are used as tags and remaining leaves as
+		## fields automatically, and leaf values are typed per their YANG type.
+		# yang_dir = "/etc/telegraf/yang"
+
+		## How many devices to poll at once. Defaults to 1 (serial).
+		# concurrency = 4
+
+		## How often a pooled connection is re-validated with a lightweight
+		## <get> before being reused. Defaults to never re-validating.
+		# health_check_interval = "30s"
+
+		## How long dialing a device may take before giving up. Defaults to 10s.
+		# dial_timeout = "10s"
 	`
 }
 
 // Description returns a description of the plugin.
 func (n *Netconf) Description() string {
-	return "Collects interface input/output bytes from NETCONF-enabled devices (Cisco/Juniper)"
+	return "Collects configurable metrics from NETCONF-enabled devices (Cisco/Juniper) via subtree or XPath filters"
 }
 
-// Gather collects metrics from all devices.
-func (n *Netconf) Gather(acc telegraf.Accumulator) error {
-	for _, device := range n.Devices {
-		session, err := n.connect(device)
+// Init loads the optional YANG schema directory and builds a Telegraf
+// parser for every subscription that set data_format. It implements
+// telegraf.Initializer.
+func (n *Netconf) Init() error {
+	if n.YangDir != "" {
+		schema, err := loadYangSchema(n.YangDir)
 		if err != nil {
-			acc.AddError(fmt.Errorf("failed to connect to %s: %v", device.Address, err))
-			continue
+			return fmt.Errorf("loading yang_dir %q: %v", n.YangDir, err)
 		}
+		n.schema = schema
+	}
 
-		rpc := `
-			<filter xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" type="subtree">
-				<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">
-					<interface>
-						<statistics/>
-					</interface>
-				</interfaces>
-			</filter>
-		`
-		reply, err := session.Exec(netconf.RawMethod(rpc))
-		if err != nil {
-			return fmt.Errorf("RPC failed: %v", err)
-		}
-		// Get the raw XML reply as []byte
-		replyBytes := []byte(reply.Data)
-
-		// Parse the XML reply
-		var result struct {
-			Interfaces struct {
-				Interface []struct {
-					Name       string `xml:"name"`
-					Statistics struct {
-						InOctets  uint64 `xml:"in-octets"`
-						OutOctets uint64 `xml:"out-octets"`
-					} `xml:"statistics"`
-				} `xml:"interface"`
-			} `xml:"interfaces"`
-		}
-		//		if err := xml.Unmarshal([]byte(reply), &result); err != nil {
-		//			acc.AddError(fmt.Errorf("XML parse failed for %s: %v", device.Address, err))
-		//			continue
-		//		}
-		if err := xml.Unmarshal(replyBytes, &result); err != nil {
-			acc.AddError(fmt.Errorf("XML parse failed for %s: %v", device.Address, err))
-			continue
+	for i := range n.Subscriptions {
+		if err := n.Subscriptions[i].buildParser(); err != nil {
+			return fmt.Errorf("subscription %q: %v", n.Subscriptions[i].Name, err)
+		}
+	}
+	for i := range n.Streams {
+		if err := n.Streams[i].buildParser(); err != nil {
+			return fmt.Errorf("stream %q: %v", n.Streams[i].Name, err)
 		}
+	}
+	return nil
+}
+
+// buildParser constructs sub's Telegraf parser from the registered Creator
+// for DataFormat, if set. It is a no-op otherwise, leaving Gather to use the
+// built-in path-driven extractor.
+func (sub *Subscription) buildParser() error {
+	if sub.DataFormat == "" {
+		return nil
+	}
+
+	creator, ok := parsers.Parsers[sub.DataFormat]
+	if !ok {
+		return fmt.Errorf("unknown data_format %q", sub.DataFormat)
+	}
 
-		// Add metrics to the accumulator
-		for _, iface := range result.Interfaces.Interface {
-			tags := map[string]string{
-				"interface": iface.Name,
-				"device":    device.Address,
-			}
-			fields := map[string]interface{}{
-				"input_bytes":  iface.Statistics.InOctets,
-				"output_bytes": iface.Statistics.OutOctets,
-			}
-			acc.AddFields("netconf_interface", fields, tags)
+	parser := creator(sub.Measurement)
+	if initializer, ok := parser.(telegraf.Initializer); ok {
+		if err := initializer.Init(); err != nil {
+			return fmt.Errorf("building %s parser: %v", sub.DataFormat, err)
 		}
 	}
+	sub.parser = parser
 	return nil
 }
 
-// connect ensures a connection exists for the given device.
-func (n *Netconf) connect(device Device) (*netconf.Session, error) {
-	n.mu.Lock()
-	defer n.mu.Unlock()
+// xmlNode is a generic XML element used to walk an <rpc-reply> payload
+// without requiring a hand-written struct per subscription.
+type xmlNode struct {
+	XMLName xml.Name
+	Content string    `xml:",chardata"`
+	Nodes   []xmlNode `xml:",any"`
+}
+
+// Gather collects metrics from all devices, polling up to n.Concurrency of
+// them at a time. Deployments that only use RFC 5277 streams (no polled
+// [[inputs.netconf.subscription]]) have nothing to do here; metrics arrive
+// through Start instead.
+func (n *Netconf) Gather(acc telegraf.Accumulator) error {
+	if len(n.Subscriptions) == 0 {
+		if len(n.Streams) == 0 {
+			return fmt.Errorf("netconf: no [[inputs.netconf.subscription]] or [[inputs.netconf.stream]] configured")
+		}
+		return nil
+	}
 
-	// Reuse existing connection if available
-	if session, ok := n.connections[device.Address]; ok {
-		return session, nil
+	concurrency := n.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
 	}
+	sem := make(chan struct{}, concurrency)
 
-	// Configure SSH client
-	sshConfig := &ssh.ClientConfig{
-		User: device.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(device.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // Use a secure callback in production!
+	var wg sync.WaitGroup
+	for _, device := range n.Devices {
+		device := device
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			n.gatherDevice(acc, device)
+		}()
 	}
+	wg.Wait()
+	return nil
+}
 
-	// Open a new connection
-	session, err := netconf.DialSSH(device.Address, sshConfig)
+// gatherDevice runs every configured subscription against a single device.
+func (n *Netconf) gatherDevice(acc telegraf.Accumulator, device Device) {
+	session, err := n.connect(device)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to %s: %v", device.Address, err)
+		acc.AddError(fmt.Errorf("%s: failed to connect: %v", device.alias(), err))
+		return
 	}
 
-	// Store the connection
-	n.connections[device.Address] = session
-	return session, nil
+	for _, sub := range n.Subscriptions {
+		if err := n.gatherSubscription(acc, device, session, sub); err != nil {
+			acc.AddError(fmt.Errorf("%s: subscription %q failed: %v", device.alias(), sub.Name, err))
+		}
+	}
 }
 
-// disconnectAll closes all active connections.
-func (n *Netconf) disconnectAll() error {
-	n.mu.Lock()
-	defer n.mu.Unlock()
+// gatherSubscription executes a single subscription's RPC against session
+// and emits one metric per element matched by sub.Path.
+func (n *Netconf) gatherSubscription(acc telegraf.Accumulator, device Device, session *netconf.Session, sub Subscription) error {
+	if sub.Origin == "xpath" && !n.hasCapability(device.Address, capabilityXPath) {
+		return fmt.Errorf("device does not advertise %s, cannot use an xpath subscription", capabilityXPath)
+	}
+
+	rpc, err := buildFilterRPC(sub)
+	if err != nil {
+		return fmt.Errorf("building filter: %v", err)
+	}
+
+	reply, err := session.Exec(netconf.RawMethod(rpc))
+	if err != nil {
+		return fmt.Errorf("RPC failed: %v", err)
+	}
+
+	if sub.parser != nil {
+		return gatherWithParser(acc, device, sub, []byte(reply.Data))
+	}
 
-	for addr, session := range n.connections {
-		session.Close()
-		delete(n.connections, addr)
+	var root xmlNode
+	if err := xml.Unmarshal([]byte(reply.Data), &root); err != nil {
+		return fmt.Errorf("XML parse failed: %v", err)
+	}
+
+	segments := splitPath(sub.Path)
+	matches := findNodes(root, segments)
+	for _, match := range matches {
+		tags, fields, counters := n.extract(sub, match)
+		if len(fields) == 0 && len(counters) == 0 {
+			continue
+		}
+		tags["device"] = device.Address
+
+		if len(fields) > 0 {
+			acc.AddFields(sub.Measurement, fields, tags)
+		}
+		if len(counters) > 0 {
+			acc.AddCounter(sub.Measurement, counters, tags)
+		}
+	}
+	return nil
+}
+
+// gatherWithParser feeds raw through sub's configured Telegraf parser
+// instead of the built-in path-driven extractor, tagging every resulting
+// metric with the originating device.
+func gatherWithParser(acc telegraf.Accumulator, device Device, sub Subscription, raw []byte) error {
+	metrics, err := sub.parser.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("parsing reply with %s parser: %v", sub.DataFormat, err)
+	}
+
+	for _, m := range metrics {
+		m.AddTag("device", device.Address)
+		acc.AddMetric(m)
 	}
 	return nil
 }
 
+// extract resolves sub's tags and fields out of match. When n.schema is
+// loaded and sub.TagPaths/FieldPaths are left empty, they're derived from
+// the list entry's YANG key statement and remaining leaves, and leaf values
+// are converted to their YANG type instead of staying strings.
+//
+// Fields are additionally split by YANG type into gauges and counters (see
+// isCounter): a field backed by a counter32/counter64 leaf is returned in
+// counters instead of fields, so callers can report it with AddCounter
+// rather than AddFields. Without a loaded schema every field is treated as
+// a gauge, since there's no type information to classify it.
+func (n *Netconf) extract(sub Subscription, match xmlNode) (tags map[string]string, fields map[string]interface{}, counters map[string]interface{}) {
+	listEntry := n.schema.lookup(splitPath(sub.Path))
+
+	tagPaths := sub.TagPaths
+	fieldPaths := sub.FieldPaths
+	if n.schema != nil && listEntry != nil {
+		if len(tagPaths) == 0 {
+			tagPaths = keyTags(listEntry)
+		}
+		if len(fieldPaths) == 0 {
+			fieldPaths = leafFields(listEntry, tagPaths)
+		}
+	}
+
+	tags = make(map[string]string)
+	for tag, path := range tagPaths {
+		if v, ok := leafValue(match, splitPath(path)); ok {
+			tags[tag] = v
+		}
+	}
+
+	fields = make(map[string]interface{})
+	counters = make(map[string]interface{})
+	for field, path := range fieldPaths {
+		relSegments := splitPath(path)
+		v, ok := leafValue(match, relSegments)
+		if !ok {
+			continue
+		}
+		entry := resolveChild(listEntry, relSegments)
+		value := convertLeaf(entry, v)
+		if isCounter(entry) {
+			counters[field] = value
+		} else {
+			fields[field] = value
+		}
+	}
+	return tags, fields, counters
+}
+
+// resolveChild walks entry.Dir down relSegments to find the schema node for
+// a field/tag path expressed relative to entry.
+func resolveChild(entry *yang.Entry, relSegments []string) *yang.Entry {
+	for _, seg := range relSegments {
+		if entry == nil {
+			return nil
+		}
+		entry = entry.Dir[seg]
+	}
+	return entry
+}
+
+// splitPath breaks a "a/b/c" path into its non-empty segments.
+func splitPath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// findNodes walks node looking for every element reachable by following
+// segments one local name at a time, XML namespace prefixes ignored.
+func findNodes(node xmlNode, segments []string) []xmlNode {
+	if len(segments) == 0 {
+		return []xmlNode{node}
+	}
+
+	var matches []xmlNode
+	for _, child := range node.Nodes {
+		if child.XMLName.Local != segments[0] {
+			continue
+		}
+		matches = append(matches, findNodes(child, segments[1:])...)
+	}
+	return matches
+}
+
+// leafValue resolves a relative path from node down to a single leaf's
+// character data.
+func leafValue(node xmlNode, segments []string) (string, bool) {
+	found := findNodes(node, segments)
+	if len(found) == 0 {
+		return "", false
+	}
+	return strings.TrimSpace(found[0].Content), true
+}
+
+// buildFilterRPC turns a Subscription into a <get> RPC carrying either a
+// subtree or an xpath filter.
+func buildFilterRPC(sub Subscription) (string, error) {
+	switch sub.Origin {
+	case "", "subtree":
+		return buildSubtreeRPC(sub)
+	case "xpath":
+		return buildXPathRPC(sub)
+	default:
+		return "", fmt.Errorf("unknown origin %q (want \"subtree\" or \"xpath\")", sub.Origin)
+	}
+}
+
+// buildSubtreeRPC nests the segments of sub.Path into XML elements. Any
+// segment whose name matches a key in sub.Namespaces gets that entry's URI
+// attached to it as a default xmlns, so a subtree spanning more than one
+// YANG module (e.g. a container augmented in from a different namespace)
+// can be expressed, not just a single namespace on the outermost element.
+func buildSubtreeRPC(sub Subscription) (string, error) {
+	segments := splitPath(sub.Path)
+	if len(segments) == 0 {
+		return "", fmt.Errorf("subscription %q: empty path", sub.Name)
+	}
+
+	var open, close string
+	for _, seg := range segments {
+		if ns, ok := sub.Namespaces[seg]; ok {
+			open += fmt.Sprintf("<%s xmlns=\"%s\">", seg, ns)
+		} else {
+			open += fmt.Sprintf("<%s>", seg)
+		}
+		close = fmt.Sprintf("</%s>", seg) + close
+	}
+
+	return fmt.Sprintf(`
+		<filter xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" type="subtree">
+			%s%s
+		</filter>
+	`, open, close), nil
+}
+
+// buildXPathRPC sends sub.Path verbatim as an XPath select expression,
+// declaring every entry of sub.Namespaces as a prefix usable in that
+// expression.
+func buildXPathRPC(sub Subscription) (string, error) {
+	if sub.Path == "" {
+		return "", fmt.Errorf("subscription %q: empty path", sub.Name)
+	}
+
+	var nsDecls strings.Builder
+	for prefix, uri := range sub.Namespaces {
+		fmt.Fprintf(&nsDecls, " xmlns:%s=\"%s\"", prefix, uri)
+	}
+
+	return fmt.Sprintf(`
+		<filter xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" type="xpath"%s select="%s"/>
+	`, nsDecls.String(), sub.Path), nil
+}
+
 // NewNetconf creates a new plugin instance.
 func NewNetconf() *Netconf {
 	return &Netconf{
-		connections: make(map[string]*netconf.Session),
+		connections: make(map[string]*pooledSession),
+		retries:     make(map[string]*retryState),
 	}
 }
 
@@ -182,7 +557,20 @@ func init() {
 	})
 }
 
-// Stop closes all active connections.
+// Stop shuts down any running streams and closes all active connections.
+//
+// disconnectAll runs before wg.Wait(), not after: a runStream goroutine
+// blocked inside Transport.Receive() only notices ctx is done between
+// messages, so on a stream with no pending notification traffic, cancelling
+// the context alone would never unblock it. Closing its session here forces
+// that Receive call to return immediately so the goroutine can observe
+// ctx.Done() and exit.
 func (n *Netconf) Stop() {
+	if n.cancel != nil {
+		n.cancel()
+		n.disconnectAll()
+		n.wg.Wait()
+		return
+	}
 	n.disconnectAll()
 }
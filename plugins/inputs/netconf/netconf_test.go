@@ -0,0 +1,274 @@
+// Copyright (C) 2025 Marc-Olivier Barre
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package netconf
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// ciscoIOSXRInterfacesReply is a trimmed <rpc-reply> recorded against a
+// Cisco IOS-XR box for ietf-interfaces statistics.
+const ciscoIOSXRInterfacesReply = `
+<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+  <data>
+    <interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">
+      <interface>
+        <name>GigabitEthernet0/0/0/0</name>
+        <statistics>
+          <in-octets>1024</in-octets>
+          <out-octets>2048</out-octets>
+        </statistics>
+      </interface>
+      <interface>
+        <name>GigabitEthernet0/0/0/1</name>
+        <statistics>
+          <in-octets>512</in-octets>
+          <out-octets>256</out-octets>
+        </statistics>
+      </interface>
+    </interfaces>
+  </data>
+</rpc-reply>
+`
+
+// junosInterfacesReply is a trimmed <rpc-reply> recorded against a Juniper
+// Junos box for the same ietf-interfaces subtree.
+const junosInterfacesReply = `
+<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+  <data>
+    <interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">
+      <interface>
+        <name>ge-0/0/0</name>
+        <statistics>
+          <in-octets>99</in-octets>
+          <out-octets>11</out-octets>
+        </statistics>
+      </interface>
+    </interfaces>
+  </data>
+</rpc-reply>
+`
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"", nil},
+		{"/", nil},
+		{"interfaces/interface", []string{"interfaces", "interface"}},
+		{"/interfaces/interface/", []string{"interfaces", "interface"}},
+	}
+
+	for _, tt := range tests {
+		got := splitPath(tt.path)
+		if len(got) != len(tt.want) {
+			t.Errorf("splitPath(%q) = %v, want %v", tt.path, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("splitPath(%q) = %v, want %v", tt.path, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func unmarshalReply(t *testing.T, raw string) xmlNode {
+	t.Helper()
+	var root xmlNode
+	if err := xml.Unmarshal([]byte(raw), &root); err != nil {
+		t.Fatalf("unmarshalling fixture: %v", err)
+	}
+	return root
+}
+
+func TestFindNodesAndLeafValue(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		reply string
+		want  []string
+	}{
+		{"cisco", ciscoIOSXRInterfacesReply, []string{"GigabitEthernet0/0/0/0", "GigabitEthernet0/0/0/1"}},
+		{"junos", junosInterfacesReply, []string{"ge-0/0/0"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			root := unmarshalReply(t, tt.reply)
+			matches := findNodes(root, splitPath("interfaces/interface"))
+			if len(matches) != len(tt.want) {
+				t.Fatalf("findNodes() returned %d matches, want %d", len(matches), len(tt.want))
+			}
+			for i, match := range matches {
+				name, ok := leafValue(match, splitPath("name"))
+				if !ok {
+					t.Fatalf("match %d: leafValue(name) not found", i)
+				}
+				if name != tt.want[i] {
+					t.Errorf("match %d name = %q, want %q", i, name, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLeafValueMissing(t *testing.T) {
+	root := unmarshalReply(t, ciscoIOSXRInterfacesReply)
+	matches := findNodes(root, splitPath("interfaces/interface"))
+	if _, ok := leafValue(matches[0], splitPath("does-not-exist")); ok {
+		t.Errorf("leafValue found a value for a path that doesn't exist")
+	}
+}
+
+func TestBuildFilterRPC(t *testing.T) {
+	sub := Subscription{
+		Name:       "interface-stats",
+		Origin:     "subtree",
+		Path:       "interfaces/interface",
+		Namespaces: map[string]string{"interfaces": "urn:ietf:params:xml:ns:yang:ietf-interfaces"},
+	}
+
+	rpc, err := buildFilterRPC(sub)
+	if err != nil {
+		t.Fatalf("buildFilterRPC: %v", err)
+	}
+	for _, want := range []string{
+		`type="subtree"`,
+		`<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">`,
+		"<interface>",
+		"</interfaces>",
+	} {
+		if !strings.Contains(rpc, want) {
+			t.Errorf("buildFilterRPC() missing %q in:\n%s", want, rpc)
+		}
+	}
+
+	sub.Origin = "xpath"
+	rpc, err = buildFilterRPC(sub)
+	if err != nil {
+		t.Fatalf("buildFilterRPC (xpath): %v", err)
+	}
+	if !strings.Contains(rpc, `type="xpath"`) || !strings.Contains(rpc, `select="interfaces/interface"`) {
+		t.Errorf("buildFilterRPC (xpath) = %q, missing expected attributes", rpc)
+	}
+
+	sub.Origin = "bogus"
+	if _, err := buildFilterRPC(sub); err == nil {
+		t.Error("buildFilterRPC with unknown origin should have failed")
+	}
+}
+
+func TestBuildSubtreeRPCMultipleNamespaces(t *testing.T) {
+	sub := Subscription{
+		Name:   "augmented-interface-stats",
+		Origin: "subtree",
+		Path:   "interfaces/interface/augmented-stats",
+		Namespaces: map[string]string{
+			"interfaces":      "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+			"augmented-stats": "urn:example:augmented-interface-stats",
+		},
+	}
+
+	rpc, err := buildFilterRPC(sub)
+	if err != nil {
+		t.Fatalf("buildFilterRPC: %v", err)
+	}
+	for _, want := range []string{
+		`<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">`,
+		"<interface>",
+		`<augmented-stats xmlns="urn:example:augmented-interface-stats">`,
+	} {
+		if !strings.Contains(rpc, want) {
+			t.Errorf("buildFilterRPC() missing %q in:\n%s", want, rpc)
+		}
+	}
+}
+
+func TestBuildSubtreeRPCEmptyPath(t *testing.T) {
+	sub := Subscription{Name: "empty", Origin: "subtree"}
+	if _, err := buildFilterRPC(sub); err == nil {
+		t.Error("buildFilterRPC with empty path should have failed")
+	}
+}
+
+func TestExtractUsesTagAndFieldPaths(t *testing.T) {
+	n := NewNetconf()
+	sub := Subscription{
+		Path:        "interfaces/interface",
+		TagPaths:    map[string]string{"interface": "name"},
+		FieldPaths:  map[string]string{"input_bytes": "statistics/in-octets", "output_bytes": "statistics/out-octets"},
+		Measurement: "netconf_interface",
+	}
+
+	root := unmarshalReply(t, ciscoIOSXRInterfacesReply)
+	matches := findNodes(root, splitPath(sub.Path))
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2", len(matches))
+	}
+
+	tags, fields, counters := n.extract(sub, matches[0])
+	if tags["interface"] != "GigabitEthernet0/0/0/0" {
+		t.Errorf("tags[interface] = %q, want GigabitEthernet0/0/0/0", tags["interface"])
+	}
+	if fields["input_bytes"] != "1024" || fields["output_bytes"] != "2048" {
+		t.Errorf("fields = %v, want input_bytes=1024 output_bytes=2048", fields)
+	}
+	if len(counters) != 0 {
+		t.Errorf("counters = %v, want empty without a loaded schema", counters)
+	}
+}
+
+func TestGatherWithParser(t *testing.T) {
+	var acc testutil.Accumulator
+
+	sub := Subscription{Name: "raw", Measurement: "netconf_raw"}
+	sub.DataFormat = "json"
+	if err := (&sub).buildParser(); err != nil {
+		t.Fatalf("buildParser(json): %v", err)
+	}
+
+	err := gatherWithParser(&acc, Device{Address: "10.0.0.1:830"}, sub, []byte(`{"value": 42}`))
+	if err != nil {
+		t.Fatalf("gatherWithParser: %v", err)
+	}
+
+	acc.AssertContainsTaggedFields(t, "netconf_raw", map[string]interface{}{"value": float64(42)}, map[string]string{"device": "10.0.0.1:830"})
+}
+
+func TestInitBuildsParserForDataFormatSubscriptions(t *testing.T) {
+	n := NewNetconf()
+	n.Subscriptions = []Subscription{
+		{Name: "raw", Measurement: "netconf_raw", DataFormat: "json"},
+	}
+
+	if err := n.Init(); err != nil {
+		t.Fatalf("Init() with data_format = %q: %v", n.Subscriptions[0].DataFormat, err)
+	}
+	if n.Subscriptions[0].parser == nil {
+		t.Error("Init() left Subscriptions[0].parser nil despite data_format being set")
+	}
+}
+
+func TestBuildParserUnknownDataFormat(t *testing.T) {
+	sub := Subscription{Name: "raw", DataFormat: "not-a-real-format"}
+	if err := (&sub).buildParser(); err == nil {
+		t.Error("buildParser() with an unregistered data_format should have failed")
+	}
+}
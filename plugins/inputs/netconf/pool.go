@@ -0,0 +1,330 @@
+// Copyright (C) 2025 Marc-Olivier Barre
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package netconf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Juniper/go-netconf/netconf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// capabilityXPath is the capability URI a device must advertise for an
+// Origin: "xpath" subscription to be usable against it.
+const capabilityXPath = "urn:ietf:params:netconf:capability:xpath:1.0"
+
+// pooledSession is a cached NETCONF session plus the capabilities it
+// negotiated at dial time and the last time it was proven alive.
+type pooledSession struct {
+	session      *netconf.Session
+	capabilities map[string]bool
+	lastCheck    time.Time
+
+	// streaming is true while an RFC 5277 notification stream owns this
+	// session; it must not be used for any other RPC, including keepalives,
+	// until the stream ends.
+	streaming bool
+}
+
+// retryState tracks exponential backoff for a device that failed to dial,
+// so a busy Gather loop doesn't hammer a device that's known to be down.
+type retryState struct {
+	nextAttempt time.Time
+	backoff     time.Duration
+}
+
+// defaultDialTimeout bounds how long dialing a device may take when
+// DialTimeout is left unset, so an unreachable device fails into the
+// backoff/retry path instead of hanging the caller forever.
+const defaultDialTimeout = 10 * time.Second
+
+// connect returns a live, capability-negotiated session for device, reusing
+// a pooled one when it is still healthy and dialing a new one otherwise.
+//
+// A session currently owned by a notification stream (see setStreaming) is
+// never handed out here: RFC 5277 dedicates that session to notifications,
+// and Gather concurrently calling Exec on it would race the stream's own
+// reader on the same transport. The owning stream goroutine holds its
+// session handle directly and does not need to call connect() again until
+// it has released ownership, so this can't starve it.
+func (n *Netconf) connect(device Device) (*netconf.Session, error) {
+	if pooled := n.pooledFor(device.Address); pooled != nil {
+		n.mu.Lock()
+		streaming := pooled.streaming
+		n.mu.Unlock()
+		if streaming {
+			return nil, fmt.Errorf("session is owned by an active notification stream, cannot poll it")
+		}
+
+		if n.sessionHealthy(device, pooled) {
+			return pooled.session, nil
+		}
+		n.forget(device.Address)
+	}
+
+	if wait, blocked := n.retryBlocked(device.Address); blocked {
+		return nil, fmt.Errorf("in reconnect backoff, next attempt in %s", wait)
+	}
+
+	timeout := time.Duration(n.DialTimeout)
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+
+	pooled, err := dialDevice(device, timeout)
+	if err != nil {
+		n.recordFailure(device.Address)
+		return nil, err
+	}
+	n.recordSuccess(device.Address)
+
+	n.mu.Lock()
+	n.connections[device.Address] = pooled
+	n.mu.Unlock()
+	return pooled.session, nil
+}
+
+// pooledFor returns the cached session for address, if any.
+func (n *Netconf) pooledFor(address string) *pooledSession {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.connections[address]
+}
+
+// setStreaming marks address's pooled session as owned by a notification
+// stream (or releases that ownership). While set, connect() refuses to
+// hand the session to any other caller (e.g. a concurrent Gather poll),
+// and the health-check keepalive in sessionHealthy leaves it alone.
+func (n *Netconf) setStreaming(address string, streaming bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if pooled, ok := n.connections[address]; ok {
+		pooled.streaming = streaming
+	}
+}
+
+// forget drops address's cached session without closing it twice; callers
+// that evict an unhealthy session are expected to have already seen it fail.
+func (n *Netconf) forget(address string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.connections, address)
+}
+
+// sessionHealthy re-validates a pooled session with a lightweight <get>
+// once HealthCheckInterval has elapsed since the last check. A zero (the
+// default) HealthCheckInterval disables keepalives: the pooled session is
+// assumed healthy until an actual RPC on it fails. A session currently
+// owned by a notification stream is never probed, since RFC 5277 forbids
+// issuing other RPCs on it.
+func (n *Netconf) sessionHealthy(device Device, pooled *pooledSession) bool {
+	interval := time.Duration(n.HealthCheckInterval)
+
+	n.mu.Lock()
+	dueForCheck := interval > 0 && !pooled.streaming && time.Since(pooled.lastCheck) >= interval
+	n.mu.Unlock()
+	if !dueForCheck {
+		return true
+	}
+
+	_, err := pooled.session.Exec(netconf.RawMethod(`<get><filter type="subtree"/></get>`))
+	if err != nil {
+		if n.Log != nil {
+			n.Log.Debugf("%s: keepalive failed, reconnecting: %v", device.alias(), err)
+		}
+		pooled.session.Close()
+		return false
+	}
+
+	n.mu.Lock()
+	pooled.lastCheck = time.Now()
+	n.mu.Unlock()
+	return true
+}
+
+// retryBlocked reports whether address is still within its reconnect
+// backoff window.
+func (n *Netconf) retryBlocked(address string) (time.Duration, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	rs, ok := n.retries[address]
+	if !ok || !time.Now().Before(rs.nextAttempt) {
+		return 0, false
+	}
+	return time.Until(rs.nextAttempt), true
+}
+
+// recordFailure grows address's backoff, capped at MaxReconnectInterval.
+func (n *Netconf) recordFailure(address string) {
+	limit := time.Duration(n.MaxReconnectInterval)
+	if limit <= 0 {
+		limit = defaultMaxReconnectInterval
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	rs, ok := n.retries[address]
+	if !ok {
+		rs = &retryState{backoff: time.Second}
+		n.retries[address] = rs
+	} else {
+		rs.backoff = nextBackoff(rs.backoff, limit)
+	}
+	rs.nextAttempt = time.Now().Add(rs.backoff)
+}
+
+// recordSuccess clears any backoff state recorded for address.
+func (n *Netconf) recordSuccess(address string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.retries, address)
+}
+
+// hasCapability reports whether address's pooled session negotiated uri at
+// dial time.
+func (n *Netconf) hasCapability(address, uri string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	pooled, ok := n.connections[address]
+	if !ok {
+		return false
+	}
+	return pooled.capabilities[uri]
+}
+
+// dialDevice opens a fresh SSH/NETCONF session to device and records the
+// capabilities it advertised in <hello>. timeout bounds the SSH handshake so
+// an unreachable device fails fast instead of blocking the caller forever.
+func dialDevice(device Device, timeout time.Duration) (*pooledSession, error) {
+	sshConfig, err := deviceSSHConfig(device, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("configuring SSH for %s: %v", device.Address, err)
+	}
+
+	session, err := netconf.DialSSH(device.Address, sshConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", device.Address, err)
+	}
+
+	capabilities := make(map[string]bool, len(session.ServerCapabilities))
+	for _, c := range session.ServerCapabilities {
+		capabilities[c] = true
+	}
+
+	return &pooledSession{
+		session:      session,
+		capabilities: capabilities,
+		lastCheck:    time.Now(),
+	}, nil
+}
+
+// deviceSSHConfig resolves device's secrets into an ssh.ClientConfig,
+// preferring key-based auth over a password when SSHPrivateKey is set.
+// timeout bounds the dial/handshake, not the lifetime of the session.
+func deviceSSHConfig(device Device, timeout time.Duration) (*ssh.ClientConfig, error) {
+	username, err := device.Username.Get()
+	if err != nil {
+		return nil, fmt.Errorf("resolving username: %v", err)
+	}
+	defer username.Destroy()
+
+	auth, err := deviceAuthMethod(device)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := deviceHostKeyCallback(device)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            username.String(),
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}, nil
+}
+
+// deviceAuthMethod builds the SSH auth method for device: its private key
+// when configured, otherwise its password.
+func deviceAuthMethod(device Device) (ssh.AuthMethod, error) {
+	if !device.SSHPrivateKey.Empty() {
+		key, err := device.SSHPrivateKey.Get()
+		if err != nil {
+			return nil, fmt.Errorf("resolving ssh_private_key: %v", err)
+		}
+		defer key.Destroy()
+
+		var signer ssh.Signer
+		if !device.SSHPrivateKeyPassphrase.Empty() {
+			passphrase, err := device.SSHPrivateKeyPassphrase.Get()
+			if err != nil {
+				return nil, fmt.Errorf("resolving ssh_private_key_passphrase: %v", err)
+			}
+			defer passphrase.Destroy()
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key.Bytes(), passphrase.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("parsing ssh_private_key: %v", err)
+			}
+		} else {
+			signer, err = ssh.ParsePrivateKey(key.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("parsing ssh_private_key: %v", err)
+			}
+		}
+		return ssh.PublicKeys(signer), nil
+	}
+
+	password, err := device.Password.Get()
+	if err != nil {
+		return nil, fmt.Errorf("resolving password: %v", err)
+	}
+	defer password.Destroy()
+	return ssh.Password(password.String()), nil
+}
+
+// deviceHostKeyCallback returns a knownhosts-backed callback when
+// KnownHostsFile is set. Without one, the connection falls back to
+// accepting any host key, which is unsafe and only meant for lab use.
+func deviceHostKeyCallback(device Device) (ssh.HostKeyCallback, error) {
+	if device.KnownHostsFile == "" {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	callback, err := knownhosts.New(device.KnownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading known_hosts %q: %v", device.KnownHostsFile, err)
+	}
+	return callback, nil
+}
+
+// disconnectAll closes all active connections.
+func (n *Netconf) disconnectAll() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for addr, pooled := range n.connections {
+		pooled.session.Close()
+		delete(n.connections, addr)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2025 Marc-Olivier Barre
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package netconf
+
+import "testing"
+
+func TestRecordFailureThenSuccess(t *testing.T) {
+	n := NewNetconf()
+	const addr = "10.0.0.1:830"
+
+	if _, blocked := n.retryBlocked(addr); blocked {
+		t.Fatalf("a device with no recorded failures should not be backoff-blocked")
+	}
+
+	n.recordFailure(addr)
+	wait, blocked := n.retryBlocked(addr)
+	if !blocked {
+		t.Fatalf("retryBlocked() = false right after recordFailure(), want true")
+	}
+	if wait <= 0 {
+		t.Errorf("retryBlocked() wait = %v, want > 0", wait)
+	}
+
+	n.recordSuccess(addr)
+	if _, blocked := n.retryBlocked(addr); blocked {
+		t.Errorf("retryBlocked() after recordSuccess() = true, want false")
+	}
+}
+
+func TestRecordFailureGrowsBackoff(t *testing.T) {
+	n := NewNetconf()
+	const addr = "10.0.0.1:830"
+
+	n.recordFailure(addr)
+	first := n.retries[addr].backoff
+
+	n.recordFailure(addr)
+	second := n.retries[addr].backoff
+
+	if second <= first {
+		t.Errorf("backoff did not grow: first=%v second=%v", first, second)
+	}
+}
+
+func TestSetStreamingOnUnknownAddressIsNoop(t *testing.T) {
+	n := NewNetconf()
+	// Must not panic when nothing has been dialed yet for this address.
+	n.setStreaming("10.0.0.1:830", true)
+}
+
+func TestHasCapability(t *testing.T) {
+	n := NewNetconf()
+	const addr = "10.0.0.1:830"
+
+	if n.hasCapability(addr, capabilityXPath) {
+		t.Fatalf("hasCapability() = true before any session was pooled")
+	}
+
+	n.connections[addr] = &pooledSession{capabilities: map[string]bool{capabilityXPath: true}}
+	if !n.hasCapability(addr, capabilityXPath) {
+		t.Errorf("hasCapability() = false, want true once the session advertises it")
+	}
+	if n.hasCapability(addr, "urn:ietf:params:netconf:capability:candidate:1.0") {
+		t.Errorf("hasCapability() reported a capability the session never advertised")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2025 Marc-Olivier Barre
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/Juniper/go-netconf/netconf"
+	"github.com/influxdata/telegraf"
+)
+
+// defaultMaxReconnectInterval caps the exponential backoff used to
+// re-establish a dropped notification stream when max_reconnect_interval
+// is left unset.
+const defaultMaxReconnectInterval = 5 * time.Minute
+
+// StreamSubscription describes an RFC 5277 <create-subscription> to
+// maintain for the life of the plugin. It reuses Subscription's path/tag
+// mapping to turn each <notification> into a metric.
+type StreamSubscription struct {
+	Subscription
+
+	Stream    string `toml:"stream"`
+	Filter    string `toml:"filter"`
+	StartTime string `toml:"start_time"`
+	StopTime  string `toml:"stop_time"`
+}
+
+// Start implements telegraf.ServiceInput. It opens one goroutine per
+// device/stream pair that keeps an RFC 5277 subscription alive for as
+// long as the plugin runs.
+func (n *Netconf) Start(acc telegraf.Accumulator) error {
+	n.acc = acc
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+
+	for _, device := range n.Devices {
+		for _, stream := range n.Streams {
+			n.wg.Add(1)
+			go n.runStream(ctx, device, stream)
+		}
+	}
+	return nil
+}
+
+// runStream keeps a single notification stream alive, reconnecting with
+// exponential backoff whenever the session drops.
+func (n *Netconf) runStream(ctx context.Context, device Device, stream StreamSubscription) {
+	defer n.wg.Done()
+
+	maxInterval := time.Duration(n.MaxReconnectInterval)
+	if maxInterval <= 0 {
+		maxInterval = defaultMaxReconnectInterval
+	}
+	backoff := time.Second
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		session, err := n.connect(device)
+		if err != nil {
+			n.acc.AddError(fmt.Errorf("%s: stream %q: connect failed: %v", device.alias(), stream.Name, err))
+			if !sleepOrDone(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff, maxInterval)
+			continue
+		}
+
+		// Claim the session for the stream's exclusive use before issuing
+		// create-subscription, so no concurrent Gather poll can land an
+		// Exec on the same transport once this goroutine starts reading.
+		n.setStreaming(device.Address, true)
+		err = n.consumeNotifications(ctx, session, device, stream)
+		n.setStreaming(device.Address, false)
+
+		if err != nil {
+			n.acc.AddError(fmt.Errorf("%s: stream %q: %v", device.alias(), stream.Name, err))
+			n.mu.Lock()
+			delete(n.connections, device.Address)
+			n.mu.Unlock()
+			if !sleepOrDone(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff, maxInterval)
+			continue
+		}
+
+		// Clean shutdown (stopTime reached or context cancelled).
+		return
+	}
+}
+
+// consumeNotifications issues <create-subscription> and then reads
+// <notification> envelopes off the session until it ends or ctx is done.
+func (n *Netconf) consumeNotifications(ctx context.Context, session *netconf.Session, device Device, stream StreamSubscription) error {
+	rpc := buildCreateSubscriptionRPC(stream)
+	if _, err := session.Exec(netconf.RawMethod(rpc)); err != nil {
+		return fmt.Errorf("create-subscription failed: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		raw, err := session.Transport.Receive()
+		if err != nil {
+			return fmt.Errorf("session closed: %v", err)
+		}
+
+		if stream.parser != nil {
+			if err := gatherWithParser(n.acc, device, stream.Subscription, raw); err != nil {
+				n.acc.AddError(fmt.Errorf("%s: stream %q: %v", device.alias(), stream.Name, err))
+			}
+			continue
+		}
+
+		var root xmlNode
+		if err := xml.Unmarshal(raw, &root); err != nil {
+			n.acc.AddError(fmt.Errorf("%s: stream %q: XML parse failed: %v", device.alias(), stream.Name, err))
+			continue
+		}
+
+		n.emitNotification(device, stream, root)
+	}
+}
+
+// emitNotification extracts tags/fields from a single <notification>
+// envelope the same way gatherSubscription does for polled replies.
+func (n *Netconf) emitNotification(device Device, stream StreamSubscription, root xmlNode) {
+	for _, match := range findNodes(root, splitPath(stream.Path)) {
+		tags, fields, counters := n.extract(stream.Subscription, match)
+		if len(fields) == 0 && len(counters) == 0 {
+			continue
+		}
+		tags["device"] = device.Address
+
+		if len(fields) > 0 {
+			n.acc.AddFields(stream.Measurement, fields, tags)
+		}
+		if len(counters) > 0 {
+			n.acc.AddCounter(stream.Measurement, counters, tags)
+		}
+	}
+}
+
+// buildCreateSubscriptionRPC assembles the RFC 5277 request for stream.
+func buildCreateSubscriptionRPC(stream StreamSubscription) string {
+	body := fmt.Sprintf("<stream>%s</stream>", stream.Stream)
+	if stream.Filter != "" {
+		body += fmt.Sprintf(`<filter type="subtree">%s</filter>`, stream.Filter)
+	}
+	if stream.StartTime != "" {
+		body += fmt.Sprintf("<startTime>%s</startTime>", stream.StartTime)
+	}
+	if stream.StopTime != "" {
+		body += fmt.Sprintf("<stopTime>%s</stopTime>", stream.StopTime)
+	}
+
+	return fmt.Sprintf(`
+		<create-subscription xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+			%s
+		</create-subscription>
+	`, body)
+}
+
+// nextBackoff doubles the backoff duration, capped at limit.
+func nextBackoff(current, limit time.Duration) time.Duration {
+	next := current * 2
+	if next > limit {
+		return limit
+	}
+	return next
+}
+
+// sleepOrDone waits for d, returning false early if ctx is cancelled.
+func sleepOrDone(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
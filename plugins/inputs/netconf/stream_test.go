@@ -0,0 +1,65 @@
+// Copyright (C) 2025 Marc-Olivier Barre
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package netconf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNextBackoff(t *testing.T) {
+	const limit = 10 * time.Second
+
+	tests := []struct {
+		current time.Duration
+		want    time.Duration
+	}{
+		{time.Second, 2 * time.Second},
+		{4 * time.Second, 8 * time.Second},
+		{8 * time.Second, limit}, // would double past the cap
+		{limit, limit},           // already at the cap
+	}
+
+	for _, tt := range tests {
+		if got := nextBackoff(tt.current, limit); got != tt.want {
+			t.Errorf("nextBackoff(%v, %v) = %v, want %v", tt.current, limit, got, tt.want)
+		}
+	}
+}
+
+func TestBuildCreateSubscriptionRPC(t *testing.T) {
+	stream := StreamSubscription{
+		Stream:    "NETCONF",
+		Filter:    "<interfaces/>",
+		StartTime: "2026-01-01T00:00:00Z",
+	}
+
+	rpc := buildCreateSubscriptionRPC(stream)
+	for _, want := range []string{
+		"<create-subscription",
+		"<stream>NETCONF</stream>",
+		`<filter type="subtree"><interfaces/></filter>`,
+		"<startTime>2026-01-01T00:00:00Z</startTime>",
+	} {
+		if !strings.Contains(rpc, want) {
+			t.Errorf("buildCreateSubscriptionRPC() missing %q in:\n%s", want, rpc)
+		}
+	}
+	if strings.Contains(rpc, "<stopTime>") {
+		t.Errorf("buildCreateSubscriptionRPC() included <stopTime> when StopTime was unset")
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (C) 2025 Marc-Olivier Barre
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package netconf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/openconfig/goyang/pkg/yang"
+)
+
+// yangSchema indexes every node of every loaded module by its slash-joined
+// path of local (prefix-less) names, so Subscription.Path/TagPaths/FieldPaths
+// can be resolved against it the same way they're resolved against a
+// <rpc-reply>.
+type yangSchema struct {
+	entries map[string]*yang.Entry
+}
+
+// loadYangSchema parses every *.yang file in dir and indexes the resulting
+// module trees.
+func loadYangSchema(dir string) (*yangSchema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yang"))
+	if err != nil {
+		return nil, fmt.Errorf("globbing %s: %v", dir, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .yang files found in %s", dir)
+	}
+
+	ms := yang.NewModules()
+	for _, file := range files {
+		if err := ms.Read(file); err != nil {
+			return nil, fmt.Errorf("reading %s: %v", file, err)
+		}
+	}
+	if errs := ms.Process(); len(errs) > 0 {
+		return nil, fmt.Errorf("processing YANG modules: %v", errs[0])
+	}
+
+	schema := &yangSchema{entries: make(map[string]*yang.Entry)}
+	for _, mod := range ms.Modules {
+		schema.index(nil, yang.ToEntry(mod))
+	}
+	return schema, nil
+}
+
+// index walks entry's children, recording each one under its full path.
+func (s *yangSchema) index(path []string, entry *yang.Entry) {
+	for name, child := range entry.Dir {
+		childPath := append(append([]string{}, path...), name)
+		s.entries[strings.Join(childPath, "/")] = child
+		if len(child.Dir) > 0 {
+			s.index(childPath, child)
+		}
+	}
+}
+
+// lookup resolves a slash-segmented path to the schema node describing it,
+// if any module loaded declares it.
+func (s *yangSchema) lookup(segments []string) *yang.Entry {
+	if s == nil {
+		return nil
+	}
+	return s.entries[strings.Join(segments, "/")]
+}
+
+// keyTags returns a tag_paths-shaped map built from a list entry's `key`
+// statement, so list keys become tags without the user repeating them.
+func keyTags(entry *yang.Entry) map[string]string {
+	if entry == nil || entry.Key == "" {
+		return nil
+	}
+	tags := make(map[string]string)
+	for _, key := range strings.Fields(entry.Key) {
+		tags[key] = key
+	}
+	return tags
+}
+
+// leafFields returns a field_paths-shaped map of every direct leaf/leaf-list
+// child of entry that isn't already used as a tag, named after its YANG
+// identifier.
+func leafFields(entry *yang.Entry, tags map[string]string) map[string]string {
+	if entry == nil {
+		return nil
+	}
+	fields := make(map[string]string)
+	for name, child := range entry.Dir {
+		if _, isTag := tags[name]; isTag {
+			continue
+		}
+		if child.IsLeaf() || child.IsLeafList() {
+			fields[name] = name
+		}
+	}
+	return fields
+}
+
+// isCounter reports whether entry describes a monotonically increasing
+// counter (YANG's counter32/counter64, or a plain type whose description
+// says so) as opposed to a gauge.
+func isCounter(entry *yang.Entry) bool {
+	if entry == nil || entry.Type == nil {
+		return false
+	}
+	name := strings.ToLower(entry.Type.Name)
+	return strings.Contains(name, "counter")
+}
+
+// convertLeaf renders raw according to entry's YANG type, falling back to
+// the raw string when entry is unknown or the value doesn't parse.
+func convertLeaf(entry *yang.Entry, raw string) interface{} {
+	if entry == nil || entry.Type == nil {
+		return raw
+	}
+
+	switch entry.Type.Kind {
+	case yang.Yint8, yang.Yint16, yang.Yint32, yang.Yint64:
+		if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return v
+		}
+	case yang.Yuint8, yang.Yuint16, yang.Yuint32, yang.Yuint64:
+		if v, err := strconv.ParseUint(raw, 10, 64); err == nil {
+			return v
+		}
+	case yang.Ydecimal64:
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			return v
+		}
+	case yang.Ybool:
+		if v, err := strconv.ParseBool(raw); err == nil {
+			return v
+		}
+	}
+	return raw
+}
@@ -0,0 +1,101 @@
+// Copyright (C) 2025 Marc-Olivier Barre
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package netconf
+
+import (
+	"testing"
+
+	"github.com/openconfig/goyang/pkg/yang"
+)
+
+func TestIsCounter(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *yang.Entry
+		want  bool
+	}{
+		{"nil entry", nil, false},
+		{"no type", &yang.Entry{}, false},
+		{"counter32", &yang.Entry{Type: &yang.YangType{Name: "counter32"}}, true},
+		{"counter64", &yang.Entry{Type: &yang.YangType{Name: "counter64"}}, true},
+		{"gauge32", &yang.Entry{Type: &yang.YangType{Name: "gauge32"}}, false},
+		{"uint32", &yang.Entry{Type: &yang.YangType{Name: "uint32"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCounter(tt.entry); got != tt.want {
+				t.Errorf("isCounter(%+v) = %v, want %v", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertLeaf(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *yang.Entry
+		raw   string
+		want  interface{}
+	}{
+		{"nil entry stays string", nil, "42", "42"},
+		{"int64", &yang.Entry{Type: &yang.YangType{Kind: yang.Yint64}}, "-7", int64(-7)},
+		{"uint32", &yang.Entry{Type: &yang.YangType{Kind: yang.Yuint32}}, "1024", uint64(1024)},
+		{"decimal64", &yang.Entry{Type: &yang.YangType{Kind: yang.Ydecimal64}}, "1.5", float64(1.5)},
+		{"bool", &yang.Entry{Type: &yang.YangType{Kind: yang.Ybool}}, "true", true},
+		{"unparseable falls back to string", &yang.Entry{Type: &yang.YangType{Kind: yang.Yint64}}, "not-a-number", "not-a-number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertLeaf(tt.entry, tt.raw)
+			if got != tt.want {
+				t.Errorf("convertLeaf(%v, %q) = %v (%T), want %v (%T)", tt.entry, tt.raw, got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyTagsAndLeafFields(t *testing.T) {
+	entry := &yang.Entry{
+		Key: "name",
+		Dir: map[string]*yang.Entry{
+			"name":     {Name: "name", Type: &yang.YangType{Kind: yang.Ystring}},
+			"in-bytes": {Name: "in-bytes", Type: &yang.YangType{Kind: yang.Yuint64}},
+		},
+	}
+	tags := keyTags(entry)
+	if tags["name"] != "name" {
+		t.Errorf("keyTags() = %v, want name -> name", tags)
+	}
+
+	fields := leafFields(entry, tags)
+	if _, ok := fields["name"]; ok {
+		t.Errorf("leafFields() included %q, which is already a tag", "name")
+	}
+	if _, ok := fields["in-bytes"]; !ok {
+		t.Errorf("leafFields() = %v, missing in-bytes", fields)
+	}
+}
+
+func TestKeyTagsNoKey(t *testing.T) {
+	if tags := keyTags(&yang.Entry{}); tags != nil {
+		t.Errorf("keyTags() = %v, want nil for an entry with no key statement", tags)
+	}
+	if tags := keyTags(nil); tags != nil {
+		t.Errorf("keyTags(nil) = %v, want nil", tags)
+	}
+}